@@ -0,0 +1,133 @@
+package libdns_ispconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultSessionTTL is used when Provider.SessionTTL is unset. ISPConfig
+// itself expires idle remoting sessions after a while; keeping our own
+// notion of the TTL lets us proactively re-login instead of relying solely
+// on the session-expired retry below.
+const defaultSessionTTL = 15 * time.Minute
+
+// defaultHTTPClient is used when Provider.HTTPClient is nil. It sets a
+// sane overall timeout so a stalled ISPConfig instance can't hang a caller
+// forever.
+var defaultHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+func (p *Provider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+
+	return defaultHTTPClient
+}
+
+func (p *Provider) sessionTTL() time.Duration {
+	if p.SessionTTL > 0 {
+		return p.SessionTTL
+	}
+
+	return defaultSessionTTL
+}
+
+// authenticate ensures the provider holds a live ISPConfig session, logging
+// in at most once across concurrent callers, and returns that session's id.
+func (p *Provider) authenticate(ctx context.Context) (string, error) {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+
+	if p.sessionId != "" && time.Now().Before(p.sessionExpiresAt) {
+		return p.sessionId, nil
+	}
+
+	data, err := p.apiRequest(ctx, "login", authRequest{Username: p.Username, Password: p.Password})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrAuthFailed, err)
+	}
+
+	sessionId, ok := data.Response.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: unexpected session id in login response", ErrAuthFailed)
+	}
+
+	p.sessionId = sessionId
+	p.sessionExpiresAt = time.Now().Add(p.sessionTTL())
+
+	p.logger().InfoContext(ctx, "authenticated with ISPConfig", "endpoint", p.Endpoint, "username", p.Username)
+
+	return sessionId, nil
+}
+
+// invalidateSession discards the current session so the next authenticate
+// call performs a fresh login.
+func (p *Provider) invalidateSession() {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+
+	p.sessionId = ""
+	p.sessionExpiresAt = time.Time{}
+}
+
+// Logout ends the current ISPConfig session, if any. Providers are not
+// required to call this, but doing so frees the session on the ISPConfig
+// side instead of waiting for it to expire.
+func (p *Provider) Logout(ctx context.Context) error {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+
+	if p.sessionId == "" {
+		return nil
+	}
+
+	_, err := p.apiRequest(ctx, "logout", struct {
+		SessionId string `json:"session_id"`
+	}{SessionId: p.sessionId})
+
+	p.sessionId = ""
+	p.sessionExpiresAt = time.Time{}
+
+	return err
+}
+
+// doRequest authenticates the provider, builds the request params with the
+// current session id and calls method, automatically re-logging in and
+// retrying once if ISPConfig reports the session expired.
+func (p *Provider) doRequest(ctx context.Context, method string, buildParams func(sessionId string) any) (response, error) {
+	sessionId, err := p.authenticate(ctx)
+	if err != nil {
+		return response{}, err
+	}
+
+	r, err := p.apiRequest(ctx, method, buildParams(sessionId))
+	if err == nil || !isSessionExpired(err) {
+		return r, err
+	}
+
+	p.invalidateSession()
+
+	sessionId, err = p.authenticate(ctx)
+	if err != nil {
+		return response{}, err
+	}
+
+	return p.apiRequest(ctx, method, buildParams(sessionId))
+}
+
+// isSessionExpired reports whether err represents ISPConfig rejecting a
+// call because the session id it was given has expired or is unknown.
+func isSessionExpired(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(apiErr.Message), "session")
+}
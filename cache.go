@@ -0,0 +1,142 @@
+package libdns_ispconfig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// defaultZoneCacheTTL is used when Provider.ZoneCacheTTL is unset.
+const defaultZoneCacheTTL = 5 * time.Minute
+
+type zoneCacheEntry struct {
+	zoneId      int
+	serverId    int
+	hasServerId bool
+	expiresAt   time.Time
+}
+
+func (p *Provider) zoneCacheTTL() time.Duration {
+	if p.ZoneCacheTTL != 0 {
+		return p.ZoneCacheTTL
+	}
+
+	return defaultZoneCacheTTL
+}
+
+func (p *Provider) cachedZone(origin string) (zoneCacheEntry, bool) {
+	if p.zoneCacheTTL() < 0 {
+		return zoneCacheEntry{}, false
+	}
+
+	p.zoneCacheMu.RLock()
+	defer p.zoneCacheMu.RUnlock()
+
+	entry, ok := p.zoneCache[origin]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return zoneCacheEntry{}, false
+	}
+
+	return *entry, true
+}
+
+func (p *Provider) storeZoneId(origin string, zoneId int) {
+	if p.zoneCacheTTL() < 0 {
+		return
+	}
+
+	p.zoneCacheMu.Lock()
+	defer p.zoneCacheMu.Unlock()
+
+	if p.zoneCache == nil {
+		p.zoneCache = make(map[string]*zoneCacheEntry)
+	}
+
+	p.zoneCache[origin] = &zoneCacheEntry{
+		zoneId:    zoneId,
+		expiresAt: time.Now().Add(p.zoneCacheTTL()),
+	}
+}
+
+func (p *Provider) storeServerId(origin string, zoneId, serverId int) {
+	if p.zoneCacheTTL() < 0 {
+		return
+	}
+
+	p.zoneCacheMu.Lock()
+	defer p.zoneCacheMu.Unlock()
+
+	if p.zoneCache == nil {
+		p.zoneCache = make(map[string]*zoneCacheEntry)
+	}
+
+	p.zoneCache[origin] = &zoneCacheEntry{
+		zoneId:      zoneId,
+		serverId:    serverId,
+		hasServerId: true,
+		expiresAt:   time.Now().Add(p.zoneCacheTTL()),
+	}
+}
+
+func (p *Provider) invalidateZoneCache(origin string) {
+	p.zoneCacheMu.Lock()
+	defer p.zoneCacheMu.Unlock()
+
+	delete(p.zoneCache, origin)
+}
+
+// resolveZoneId returns the ISPConfig zone id for origin, using the cache
+// when possible and falling back to dns_zone_get_id otherwise.
+func (p *Provider) resolveZoneId(ctx context.Context, origin string) (int, error) {
+	if cached, ok := p.cachedZone(origin); ok {
+		return cached.zoneId, nil
+	}
+
+	zoneId, err := p.getZoneId(ctx, origin)
+	if err != nil {
+		if zoneMayHaveMoved(err) {
+			p.invalidateZoneCache(origin)
+		}
+		return 0, err
+	}
+
+	p.storeZoneId(origin, zoneId)
+
+	return zoneId, nil
+}
+
+// resolveServerId returns the ISPConfig server id hosting zoneId, using the
+// cache when possible and falling back to dns_zone_get otherwise.
+func (p *Provider) resolveServerId(ctx context.Context, origin string, zoneId int) (int, error) {
+	if cached, ok := p.cachedZone(origin); ok && cached.zoneId == zoneId && cached.hasServerId {
+		return cached.serverId, nil
+	}
+
+	serverId, err := p.getServerId(ctx, zoneId)
+	if err != nil {
+		if zoneMayHaveMoved(err) {
+			p.invalidateZoneCache(origin)
+		}
+		return 0, err
+	}
+
+	p.storeServerId(origin, zoneId, serverId)
+
+	return serverId, nil
+}
+
+// zoneMayHaveMoved reports whether err suggests the cached zone/server
+// mapping for an origin is stale and should be dropped.
+func zoneMayHaveMoved(err error) bool {
+	if err == ErrZoneNotFound {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return strings.Contains(strings.ToLower(apiErr.Message), "zone")
+	}
+
+	return false
+}
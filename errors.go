@@ -0,0 +1,41 @@
+package libdns_ispconfig
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAuthFailed is returned when ISPConfig rejects the configured
+// credentials during login.
+var ErrAuthFailed = errors.New("ispconfig: authentication failed")
+
+// ErrZoneNotFound is returned when ISPConfig has no zone matching the
+// requested origin.
+var ErrZoneNotFound = errors.New("ispconfig: zone not found")
+
+// APIError reports a failure returned by the ISPConfig remoting API itself,
+// as opposed to a transport-level or decoding error.
+type APIError struct {
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ispconfig: api error (%s): %s", e.Code, e.Message)
+}
+
+// DecodeError reports that a remote method's response body could not be
+// parsed as JSON, as opposed to the request failing at the transport level
+// or ISPConfig reporting an API-level failure.
+type DecodeError struct {
+	Method string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("ispconfig: decoding %s response: %s", e.Method, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
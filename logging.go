@@ -0,0 +1,68 @@
+package libdns_ispconfig
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+)
+
+// version is the module version reported in the default User-Agent.
+const version = "0.1.0"
+
+// defaultUserAgent is used when Provider.UserAgent is unset.
+const defaultUserAgent = "libdns-ispconfig/" + version
+
+// noopLogger discards all log records. It is used when Provider.Logger is
+// nil so the provider stays silent by default.
+var noopLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func (p *Provider) userAgent() string {
+	if p.UserAgent != "" {
+		return p.UserAgent
+	}
+
+	return defaultUserAgent
+}
+
+func (p *Provider) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+
+	return noopLogger
+}
+
+// redactedFields are stripped from request/response bodies before they're
+// logged at debug level.
+var redactedFields = []string{"password", "session_id"}
+
+// redactJSON returns a copy of a JSON-encoded request/response body with
+// password and session_id fields replaced, suitable for debug logging. For
+// the login method, the response field is also redacted, since a successful
+// login returns the new session id there rather than under a "session_id"
+// key. If body isn't a JSON object it's returned unchanged.
+func redactJSON(method string, body []byte) string {
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+
+	for _, field := range redactedFields {
+		if _, ok := fields[field]; ok {
+			fields[field] = "REDACTED"
+		}
+	}
+
+	if method == "login" {
+		if _, ok := fields["response"]; ok {
+			fields["response"] = "REDACTED"
+		}
+	}
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return string(body)
+	}
+
+	return string(redacted)
+}
@@ -1,6 +1,6 @@
 // Package libdns_ispconfig implements a DNS record management client compatible
-// with the libdns interfaces for ISPConfig. TODO: Implement other entry types.
-// This package only implements the management of TXT entries for ACME DNS challenges.
+// with the libdns interfaces for ISPConfig, supporting the A, AAAA, CNAME,
+// MX, NS, SRV, CAA, PTR and TXT record types.
 package libdns_ispconfig
 
 import (
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -24,8 +25,32 @@ type Provider struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 
-	sessionId string
-	authMutex sync.Mutex
+	// HTTPClient is used for all requests to the ISPConfig remoting API.
+	// If nil, a package-level client with a sane timeout is used.
+	HTTPClient *http.Client `json:"-"`
+	// SessionTTL controls how long a login session is reused before
+	// authenticate transparently logs in again. If zero, defaultSessionTTL
+	// is used.
+	SessionTTL time.Duration `json:"-"`
+	// ZoneCacheTTL controls how long a zone's id and server id are cached
+	// for, to avoid a dns_zone_get_id/dns_zone_get round-trip on every
+	// call. If zero, defaultZoneCacheTTL is used; a negative value
+	// disables the cache.
+	ZoneCacheTTL time.Duration `json:"-"`
+	// UserAgent is sent with every request to ISPConfig. If empty,
+	// defaultUserAgent is used.
+	UserAgent string `json:"-"`
+	// Logger receives info-level logs for authentications and record
+	// mutations, and debug-level logs of request/response bodies with
+	// the password and session id redacted. If nil, logging is disabled.
+	Logger *slog.Logger `json:"-"`
+
+	sessionId        string
+	sessionExpiresAt time.Time
+	authMutex        sync.Mutex
+
+	zoneCache   map[string]*zoneCacheEntry
+	zoneCacheMu sync.RWMutex
 }
 
 type authRequest struct {
@@ -64,6 +89,16 @@ type changeParams struct {
 	TTL          int    `json:"ttl"`
 	UpdateSerial bool   `json:"update_serial"`
 	Stamp        string `json:"stamp"`
+
+	// Aux carries the MX/SRV priority.
+	Aux int `json:"aux,omitempty"`
+	// Weight, Port and Target are only used for SRV records.
+	Weight int    `json:"weight,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Flag and Tag are only used for CAA records.
+	Flag int    `json:"flag,omitempty"`
+	Tag  string `json:"tag,omitempty"`
 }
 
 type changeRequest struct {
@@ -79,21 +114,29 @@ type deleteRequest struct {
 	PrimaryId string `json:"primary_id"`
 }
 
-func (p *Provider) apiRequest(ctx context.Context, method string, data any) response {
+func (p *Provider) apiRequest(ctx context.Context, method string, data any) (response, error) {
 	endpoint := fmt.Sprintf("%s?%s", p.Endpoint, method)
-	reqBody, _ := json.Marshal(data)
+	reqBody, err := json.Marshal(data)
+
+	if err != nil {
+		return response{}, fmt.Errorf("ispconfig: encoding %s request: %w", method, err)
+	}
 
 	request, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
 
 	if err != nil {
-		panic(err)
+		return response{}, fmt.Errorf("ispconfig: building %s request: %w", method, err)
 	}
 
 	request.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(request)
+	request.Header.Set("User-Agent", p.userAgent())
+
+	p.logger().DebugContext(ctx, "ispconfig api request", "method", method, "body", redactJSON(method, reqBody))
+
+	resp, err := p.httpClient().Do(request)
 
 	if err != nil {
-		panic(err)
+		return response{}, fmt.Errorf("ispconfig: calling %s: %w", method, err)
 	}
 
 	defer resp.Body.Close()
@@ -101,93 +144,99 @@ func (p *Provider) apiRequest(ctx context.Context, method string, data any) resp
 	body, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		panic(err)
+		return response{}, fmt.Errorf("ispconfig: reading %s response: %w", method, err)
 	}
 
+	p.logger().DebugContext(ctx, "ispconfig api response", "method", method, "body", redactJSON(method, body))
+
 	var r response
 	err = json.Unmarshal(body, &r)
 
 	if err != nil {
-		panic(err)
+		return response{}, &DecodeError{Method: method, Err: err}
 	}
 
 	if r.Response == false {
-		panic(string(body))
+		return response{}, &APIError{Code: r.Code, Message: r.Message}
 	}
 
-	return r
+	return r, nil
 }
 
-func (p *Provider) authenticate(ctx context.Context) {
+func (p *Provider) getZoneId(ctx context.Context, origin string) (int, error) {
+	origin = strings.TrimSuffix(origin, ".")
 
-	if p.sessionId != "" {
-		return
-	}
-
-	p.authMutex.Lock()
-
-	if p.sessionId != "" {
-		return
+	data, err := p.doRequest(ctx, "dns_zone_get_id", func(sessionId string) any {
+		return zoneIdRequest{SessionId: sessionId, Origin: origin}
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	data := p.apiRequest(ctx, "login", authRequest{Username: p.Username, Password: p.Password})
-	sessionId, ok := data.Response.(string)
-
-	if !ok {
-		panic("Session id corrupted")
+	zoneId, ok := data.Response.(float64)
+	if !ok || zoneId == 0 {
+		return 0, ErrZoneNotFound
 	}
-	p.sessionId = sessionId
 
-	p.authMutex.Unlock()
+	return int(zoneId), nil
 }
 
-func (p *Provider) getZoneId(ctx context.Context, origin string) int {
-	if p.sessionId == "" {
-		panic("Not logged in.")
+func (p *Provider) getServerId(ctx context.Context, zoneId int) (int, error) {
+	data, err := p.doRequest(ctx, "dns_zone_get", func(sessionId string) any {
+		return zoneRequest{SessionId: sessionId, PrimaryId: zoneId}
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	if origin[len(origin)-1:] == "." {
-		origin = origin[:len(origin)-1]
+	entry, ok := data.Response.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("ispconfig: unexpected dns_zone_get response shape")
 	}
 
-	data := p.apiRequest(ctx, "dns_zone_get_id", zoneIdRequest{SessionId: p.sessionId, Origin: origin})
-
-	return int(data.Response.(float64))
-}
-
-func (p *Provider) getServerId(ctx context.Context, zoneId int) int {
-	if p.sessionId == "" {
-		panic("Not logged in.")
+	serverIdStr, ok := entry["server_id"].(string)
+	if !ok {
+		return 0, fmt.Errorf("ispconfig: unexpected dns_zone_get response shape")
 	}
 
-	data := p.apiRequest(ctx, "dns_zone_get", zoneRequest{SessionId: p.sessionId, PrimaryId: zoneId})
-	entry := data.Response.(map[string]interface{})
-	serverId, _ := strconv.ParseInt(entry["server_id"].(string), 10, 64)
+	serverId, err := strconv.ParseInt(serverIdStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ispconfig: parsing server_id: %w", err)
+	}
 
-	return int(serverId)
+	return int(serverId), nil
 }
 
 // GetRecords lists all the records in the zone.
 func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
-	p.authenticate(ctx)
-	zoneId := p.getZoneId(ctx, zone)
-	data := p.apiRequest(ctx, "dns_rr_get_all_by_zone", entriesRequest{SessionId: p.sessionId, ZoneId: zoneId})
+	zoneId, err := p.resolveZoneId(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
 
-	var records []libdns.Record
+	data, err := p.doRequest(ctx, "dns_rr_get_all_by_zone", func(sessionId string) any {
+		return entriesRequest{SessionId: sessionId, ZoneId: zoneId}
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, value := range data.Response.([]interface{}) {
-		entry := value.(map[string]interface{})
+	entries, ok := data.Response.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ispconfig: unexpected dns_rr_get_all_by_zone response shape")
+	}
+
+	var records []libdns.Record
 
-		ttl, _ := strconv.ParseInt(entry["ttl"].(string), 10, 64)
-		priority, _ := strconv.ParseInt(entry["aux"].(string), 10, 64)
+	for _, value := range entries {
+		entry, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ispconfig: unexpected record entry shape")
+		}
 
-		record := libdns.Record{
-			ID:       entry["id"].(string),
-			Type:     entry["type"].(string),
-			Name:     entry["name"].(string),
-			Value:    entry["data"].(string),
-			TTL:      time.Duration(ttl),
-			Priority: int(priority),
+		record, err := recordFromEntry(entry)
+		if err != nil {
+			return nil, err
 		}
 
 		records = append(records, record)
@@ -198,42 +247,42 @@ func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record
 
 // AppendRecords adds records to the zone. It returns the records that were added.
 func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.authenticate(ctx)
-	zoneId := p.getZoneId(ctx, zone)
-	serverId := p.getServerId(ctx, zoneId)
+	zoneId, err := p.resolveZoneId(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	serverId, err := p.resolveServerId(ctx, zone, zoneId)
+	if err != nil {
+		return nil, err
+	}
 
 	var addedRecords []libdns.Record
 	for _, record := range records {
-		if strings.ToLower(record.Type) == "txt" {
+		methods, ok := supportedRecordTypes[strings.ToUpper(record.Type)]
+		if !ok {
+			continue
+		}
 
-			ttl := int(record.TTL)
+		rrType := strings.ToUpper(record.Type)
+		params := buildChangeParams(record, serverId, zoneId)
 
-			if ttl == 0 {
-				ttl = 60
-			}
+		data, err := p.doRequest(ctx, methods.Add, func(sessionId string) any {
+			return changeRequest{SessionId: sessionId, ClientId: nil, RRType: rrType, Params: params}
+		})
+		if err != nil {
+			return addedRecords, err
+		}
 
-			change := changeRequest{
-				SessionId: p.sessionId,
-				ClientId:  nil,
-				RRType:    "TXT",
-				Params: changeParams{
-					ServerId:     serverId,
-					Name:         record.Name,
-					Active:       "Y",
-					Type:         "TXT",
-					Data:         record.Value,
-					ZoneId:       zoneId,
-					TTL:          ttl,
-					UpdateSerial: true,
-					Stamp:        time.Now().Format("2006-01-02 15:04:05"),
-				},
-			}
+		id, ok := data.Response.(string)
+		if !ok {
+			return addedRecords, fmt.Errorf("ispconfig: unexpected %s response shape", methods.Add)
+		}
 
-			data := p.apiRequest(ctx, "dns_txt_add", change)
+		record.ID = id
+		addedRecords = append(addedRecords, record)
 
-			record.ID = data.Response.(string)
-			addedRecords = append(addedRecords, record)
-		}
+		p.logger().InfoContext(ctx, "added DNS record", "zone", zone, "type", rrType, "name", record.Name)
 	}
 
 	return addedRecords, nil
@@ -242,43 +291,52 @@ func (p *Provider) AppendRecords(ctx context.Context, zone string, records []lib
 // SetRecords sets the records in the zone, either by updating existing records or creating new ones.
 // It returns the updated records.
 func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.authenticate(ctx)
-	zoneId := p.getZoneId(ctx, zone)
-	serverId := p.getServerId(ctx, zoneId)
+	zoneId, err := p.resolveZoneId(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	serverId, err := p.resolveServerId(ctx, zone, zoneId)
+	if err != nil {
+		return nil, err
+	}
 
 	var addedRecords []libdns.Record
 	for _, record := range records {
-		if strings.ToLower(record.Type) == "txt" {
-			ttl := int(record.TTL)
+		methods, ok := supportedRecordTypes[strings.ToUpper(record.Type)]
+		if !ok {
+			continue
+		}
 
-			if ttl == 0 {
-				ttl = 60
-			}
+		rrType := strings.ToUpper(record.Type)
+		params := buildChangeParams(record, serverId, zoneId)
 
-			change := changeRequest{
-				SessionId: p.sessionId,
-				ClientId:  nil,
-				RRType:    "TXT",
-				Params: changeParams{
-					ServerId:     serverId,
-					Name:         record.Name,
-					Active:       "Y",
-					Type:         "TXT",
-					Data:         record.Value,
-					ZoneId:       zoneId,
-					TTL:          ttl,
-					UpdateSerial: true,
-					Stamp:        time.Now().Format("2006-01-02 15:04:05"),
-				},
+		if record.ID == "" {
+			data, err := p.doRequest(ctx, methods.Add, func(sessionId string) any {
+				return changeRequest{SessionId: sessionId, ClientId: nil, RRType: rrType, Params: params}
+			})
+			if err != nil {
+				return addedRecords, err
 			}
 
-			if record.ID != "" {
-				change.PrimaryId = record.ID
+			id, ok := data.Response.(string)
+			if !ok {
+				return addedRecords, fmt.Errorf("ispconfig: unexpected %s response shape", methods.Add)
 			}
 
-			p.apiRequest(ctx, "dns_txt_update", change)
-			addedRecords = append(addedRecords, record)
+			record.ID = id
+		} else {
+			_, err := p.doRequest(ctx, methods.Update, func(sessionId string) any {
+				return changeRequest{SessionId: sessionId, PrimaryId: record.ID, ClientId: nil, RRType: rrType, Params: params}
+			})
+			if err != nil {
+				return addedRecords, err
+			}
 		}
+
+		addedRecords = append(addedRecords, record)
+
+		p.logger().InfoContext(ctx, "set DNS record", "zone", zone, "type", rrType, "name", record.Name)
 	}
 
 	return addedRecords, nil
@@ -286,17 +344,23 @@ func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns
 
 // DeleteRecords deletes the records from the zone. It returns the records that were deleted.
 func (p *Provider) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	p.authenticate(ctx)
-
 	var removedRecords []libdns.Record
 	for _, record := range records {
-		if strings.ToLower(record.Type) == "txt" && record.ID != "" {
-			p.apiRequest(ctx, "dns_txt_delete", deleteRequest{
-				SessionId: p.sessionId,
-				PrimaryId: record.ID,
-			})
-			removedRecords = append(removedRecords, record)
+		methods, ok := supportedRecordTypes[strings.ToUpper(record.Type)]
+		if !ok || record.ID == "" {
+			continue
 		}
+
+		_, err := p.doRequest(ctx, methods.Delete, func(sessionId string) any {
+			return deleteRequest{SessionId: sessionId, PrimaryId: record.ID}
+		})
+		if err != nil {
+			return removedRecords, err
+		}
+
+		removedRecords = append(removedRecords, record)
+
+		p.logger().InfoContext(ctx, "deleted DNS record", "zone", zone, "type", record.Type, "name", record.Name)
 	}
 
 	return removedRecords, nil
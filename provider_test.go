@@ -0,0 +1,462 @@
+package libdns_ispconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// fakeISPConfig is a minimal, in-memory stand-in for ISPConfig's remoting
+// API. It speaks the same quirky dialect as the real thing: methods are
+// selected via a bare "?method" query string, numeric fields are encoded as
+// strings, and failures are reported as {"response": false} rather than an
+// HTTP error status.
+type fakeISPConfig struct {
+	mu sync.Mutex
+
+	username, password string
+
+	loginCalls    int32
+	sessionSerial int
+	sessions      map[string]bool
+
+	zoneIds   map[string]int // origin -> zone id
+	serverIds map[int]int    // zone id -> server id
+	records   map[int][]map[string]interface{}
+	nextID    int
+
+	// expireSession, if set, is accepted as valid for login but rejected
+	// with a "session expired" error on its first subsequent use.
+	expireSession     string
+	expireSessionUsed bool
+
+	// malformedMethod, if set, makes that one method return invalid JSON.
+	malformedMethod string
+}
+
+func newFakeISPConfig() *fakeISPConfig {
+	return &fakeISPConfig{
+		username:  "admin",
+		password:  "secret",
+		sessions:  make(map[string]bool),
+		zoneIds:   map[string]int{"example.com": 1},
+		serverIds: map[int]int{1: 1},
+		records:   make(map[int][]map[string]interface{}),
+		nextID:    100,
+	}
+}
+
+func (f *fakeISPConfig) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.RawQuery
+
+		if method == f.malformedMethod {
+			w.Write([]byte("{not json"))
+			return
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		switch method {
+		case "login":
+			f.handleLogin(w, body)
+		case "logout":
+			f.handleLogout(w, body)
+		case "dns_zone_get_id":
+			f.handleZoneGetId(w, body)
+		case "dns_zone_get":
+			f.handleZoneGet(w, body)
+		case "dns_rr_get_all_by_zone":
+			f.handleRRGetAllByZone(w, body)
+		default:
+			f.handleRecordChange(w, method, body)
+		}
+	}
+}
+
+func (f *fakeISPConfig) handleLogin(w http.ResponseWriter, body map[string]interface{}) {
+	atomic.AddInt32(&f.loginCalls, 1)
+
+	if body["username"] != f.username || body["password"] != f.password {
+		writeFailure(w, "login_failed", "invalid username or password")
+		return
+	}
+
+	f.sessionSerial++
+	sessionId := fmt.Sprintf("session-%d", f.sessionSerial)
+	f.sessions[sessionId] = true
+
+	writeSuccess(w, sessionId)
+}
+
+func (f *fakeISPConfig) handleLogout(w http.ResponseWriter, body map[string]interface{}) {
+	delete(f.sessions, stringField(body, "session_id"))
+	writeSuccess(w, true)
+}
+
+func (f *fakeISPConfig) checkSession(w http.ResponseWriter, body map[string]interface{}) bool {
+	sessionId := stringField(body, "session_id")
+
+	if sessionId == f.expireSession && !f.expireSessionUsed {
+		f.expireSessionUsed = true
+		writeFailure(w, "session_expired", "session expired")
+		return false
+	}
+
+	if !f.sessions[sessionId] {
+		writeFailure(w, "session_expired", "session expired")
+		return false
+	}
+
+	return true
+}
+
+func (f *fakeISPConfig) handleZoneGetId(w http.ResponseWriter, body map[string]interface{}) {
+	if !f.checkSession(w, body) {
+		return
+	}
+
+	origin := stringField(body, "origin")
+	zoneId, ok := f.zoneIds[origin]
+	if !ok {
+		writeSuccess(w, 0)
+		return
+	}
+
+	writeSuccess(w, zoneId)
+}
+
+func (f *fakeISPConfig) handleZoneGet(w http.ResponseWriter, body map[string]interface{}) {
+	if !f.checkSession(w, body) {
+		return
+	}
+
+	zoneId := int(numberField(body, "primary_id"))
+	serverId, ok := f.serverIds[zoneId]
+	if !ok {
+		writeFailure(w, "zone_not_found", "no such zone")
+		return
+	}
+
+	writeSuccess(w, map[string]interface{}{"server_id": strconv.Itoa(serverId)})
+}
+
+func (f *fakeISPConfig) handleRRGetAllByZone(w http.ResponseWriter, body map[string]interface{}) {
+	if !f.checkSession(w, body) {
+		return
+	}
+
+	zoneId := int(numberField(body, "zone_id"))
+	writeSuccess(w, f.records[zoneId])
+}
+
+func (f *fakeISPConfig) handleRecordChange(w http.ResponseWriter, method string, body map[string]interface{}) {
+	if !f.checkSession(w, body) {
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(method, "_add"):
+		params, _ := body["params"].(map[string]interface{})
+		zoneId := int(numberField(params, "zone"))
+
+		f.nextID++
+		id := f.nextID
+
+		entry := map[string]interface{}{
+			"id":     strconv.Itoa(id),
+			"type":   params["type"],
+			"name":   params["name"],
+			"data":   params["data"],
+			"ttl":    strconv.Itoa(int(numberField(params, "ttl"))),
+			"aux":    strconv.Itoa(int(numberField(params, "aux"))),
+			"weight": strconv.Itoa(int(numberField(params, "weight"))),
+			"port":   strconv.Itoa(int(numberField(params, "port"))),
+			"target": params["target"],
+			"flag":   strconv.Itoa(int(numberField(params, "flag"))),
+			"tag":    params["tag"],
+		}
+
+		f.records[zoneId] = append(f.records[zoneId], entry)
+		writeSuccess(w, strconv.Itoa(id))
+
+	case strings.HasSuffix(method, "_update"), strings.HasSuffix(method, "_delete"):
+		writeSuccess(w, true)
+
+	default:
+		writeFailure(w, "unknown_method", "no such remote method: "+method)
+	}
+}
+
+func writeSuccess(w http.ResponseWriter, value any) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":     "ok",
+		"message":  "",
+		"response": value,
+	})
+}
+
+func writeFailure(w http.ResponseWriter, code, message string) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":     code,
+		"message":  message,
+		"response": false,
+	})
+}
+
+func stringField(body map[string]interface{}, key string) string {
+	s, _ := body[key].(string)
+	return s
+}
+
+func numberField(body map[string]interface{}, key string) float64 {
+	switch v := body[key].(type) {
+	case float64:
+		return v
+	case string:
+		n, _ := strconv.ParseFloat(v, 64)
+		return n
+	default:
+		return 0
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeISPConfig) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(fake.handler())
+	t.Cleanup(server.Close)
+
+	return &Provider{
+		Endpoint: server.URL,
+		Username: fake.username,
+		Password: fake.password,
+	}
+}
+
+func TestAppendSetDeleteRoundTrip(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	added, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token-value", TTL: 60 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if len(added) != 1 || added[0].ID == "" {
+		t.Fatalf("AppendRecords returned %+v", added)
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	if len(records) != 1 || records[0].Value != "token-value" {
+		t.Fatalf("GetRecords returned %+v", records)
+	}
+
+	added[0].Value = "updated-value"
+	set, err := p.SetRecords(ctx, "example.com", added)
+	if err != nil {
+		t.Fatalf("SetRecords: %v", err)
+	}
+	if len(set) != 1 {
+		t.Fatalf("SetRecords returned %+v", set)
+	}
+
+	deleted, err := p.DeleteRecords(ctx, "example.com", set)
+	if err != nil {
+		t.Fatalf("DeleteRecords: %v", err)
+	}
+	if len(deleted) != 1 {
+		t.Fatalf("DeleteRecords returned %+v", deleted)
+	}
+}
+
+func TestAppendRecordsReLoginsOnSessionExpiry(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	sessionId, err := p.authenticate(ctx)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.expireSession = sessionId
+	fake.mu.Unlock()
+
+	added, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token-value"},
+	})
+	if err != nil {
+		t.Fatalf("AppendRecords after session expiry: %v", err)
+	}
+	if len(added) != 1 {
+		t.Fatalf("AppendRecords returned %+v", added)
+	}
+
+	if atomic.LoadInt32(&fake.loginCalls) != 2 {
+		t.Fatalf("expected exactly one re-login, got %d total logins", fake.loginCalls)
+	}
+}
+
+func TestAppendRecordsConcurrentLoginIsSingleflight(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	const goroutines = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+
+			_, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+				{Type: "TXT", Name: fmt.Sprintf("_acme-challenge-%d", n), Value: "token-value"},
+			})
+			errs <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AppendRecords: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&fake.loginCalls); got != 1 {
+		t.Fatalf("expected exactly 1 login across %d concurrent callers, got %d", goroutines, got)
+	}
+}
+
+func TestGetRecordsMalformedResponseReturnsError(t *testing.T) {
+	fake := newFakeISPConfig()
+	fake.malformedMethod = "dns_rr_get_all_by_zone"
+	p := newTestProvider(t, fake)
+
+	_, err := p.GetRecords(context.Background(), "example.com")
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected a *DecodeError, got %v", err)
+	}
+	if decodeErr.Method != "dns_rr_get_all_by_zone" {
+		t.Fatalf("expected DecodeError for dns_rr_get_all_by_zone, got %q", decodeErr.Method)
+	}
+}
+
+func TestAppendRecordsSRVRoundTrip(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	added, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "SRV", Name: "_sip._tcp", Value: "5060 sipserver.example.com.", Priority: 10, Weight: 20, TTL: 60 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if len(added) != 1 || added[0].ID == "" {
+		t.Fatalf("AppendRecords returned %+v", added)
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("GetRecords returned %+v", records)
+	}
+
+	got := records[0]
+	if got.Value != "5060 sipserver.example.com." {
+		t.Fatalf("expected Value %q, got %q", "5060 sipserver.example.com.", got.Value)
+	}
+	if got.Priority != 10 {
+		t.Fatalf("expected Priority 10, got %d", got.Priority)
+	}
+	if got.Weight != 20 {
+		t.Fatalf("expected Weight 20, got %d", got.Weight)
+	}
+}
+
+func TestAppendRecordsCAARoundTrip(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	ctx := context.Background()
+
+	added, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "CAA", Name: "@", Value: `0 issue "letsencrypt.org"`, TTL: 60 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("AppendRecords: %v", err)
+	}
+	if len(added) != 1 || added[0].ID == "" {
+		t.Fatalf("AppendRecords returned %+v", added)
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("GetRecords: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("GetRecords returned %+v", records)
+	}
+
+	got := records[0]
+	if got.Value != `0 issue "letsencrypt.org"` {
+		t.Fatalf("expected Value %q, got %q", `0 issue "letsencrypt.org"`, got.Value)
+	}
+}
+
+func TestGetRecordsUnknownZoneReturnsTypedError(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+
+	_, err := p.GetRecords(context.Background(), "not-a-zone.example")
+	if err != ErrZoneNotFound {
+		t.Fatalf("expected ErrZoneNotFound, got %v", err)
+	}
+}
+
+func TestAppendRecordsAuthFailureReturnsTypedError(t *testing.T) {
+	fake := newFakeISPConfig()
+	p := newTestProvider(t, fake)
+	p.Password = "wrong-password"
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token-value"},
+	})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package libdns_ispconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// recordMethods holds the ISPConfig remote-method names used to manage a
+// given resource record type.
+type recordMethods struct {
+	Add    string
+	Update string
+	Delete string
+}
+
+// supportedRecordTypes maps an upper-cased record type to the ISPConfig
+// remote methods that create, update and delete it. Only types ISPConfig's
+// remoting API actually exposes are listed here; anything else is skipped,
+// matching the pre-existing behavior for unrecognized types.
+var supportedRecordTypes = map[string]recordMethods{
+	"A":     {"dns_a_add", "dns_a_update", "dns_a_delete"},
+	"AAAA":  {"dns_aaaa_add", "dns_aaaa_update", "dns_aaaa_delete"},
+	"CNAME": {"dns_cname_add", "dns_cname_update", "dns_cname_delete"},
+	"MX":    {"dns_mx_add", "dns_mx_update", "dns_mx_delete"},
+	"NS":    {"dns_ns_add", "dns_ns_update", "dns_ns_delete"},
+	"SRV":   {"dns_srv_add", "dns_srv_update", "dns_srv_delete"},
+	"CAA":   {"dns_caa_add", "dns_caa_update", "dns_caa_delete"},
+	"PTR":   {"dns_ptr_add", "dns_ptr_update", "dns_ptr_delete"},
+	"TXT":   {"dns_txt_add", "dns_txt_update", "dns_txt_delete"},
+}
+
+// buildChangeParams translates a libdns.Record into the changeParams shape
+// expected by the ISPConfig remoting API for its record type, filling in
+// the type-specific fields (MX/SRV priority, SRV weight/port/target parsed
+// out of Weight/Value, CAA flag/tag) on top of the common ones.
+func buildChangeParams(record libdns.Record, serverId, zoneId int) changeParams {
+	rrType := strings.ToUpper(record.Type)
+
+	ttl := int(record.TTL.Seconds())
+	if ttl == 0 {
+		ttl = 60
+	}
+
+	params := changeParams{
+		ServerId:     serverId,
+		Name:         record.Name,
+		Active:       "Y",
+		Type:         rrType,
+		Data:         record.Value,
+		ZoneId:       zoneId,
+		TTL:          ttl,
+		UpdateSerial: true,
+		Stamp:        time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	switch rrType {
+	case "MX":
+		params.Aux = int(record.Priority)
+	case "SRV":
+		port, target := splitSRVValue(record.Value)
+		params.Aux = int(record.Priority)
+		params.Weight = int(record.Weight)
+		params.Port = port
+		params.Target = target
+		params.Data = target
+	case "CAA":
+		flag, tag, value := splitCAAValue(record.Value)
+		params.Flag = flag
+		params.Tag = tag
+		params.Data = value
+	}
+
+	return params
+}
+
+// recordFromEntry converts one entry of a dns_rr_get_all_by_zone response
+// into a libdns.Record, reconstructing the type-specific value (SRV
+// weight/port/target, CAA flag/tag) so that records round-trip through
+// GetRecords/SetRecords unchanged and through libdns helpers like
+// SRV.ToRecord/ToSRV.
+func recordFromEntry(entry map[string]interface{}) (libdns.Record, error) {
+	id, ok := entry["id"].(string)
+	if !ok {
+		return libdns.Record{}, fmt.Errorf("ispconfig: record entry missing id")
+	}
+
+	rrType, ok := entry["type"].(string)
+	if !ok {
+		return libdns.Record{}, fmt.Errorf("ispconfig: record entry missing type")
+	}
+
+	name, ok := entry["name"].(string)
+	if !ok {
+		return libdns.Record{}, fmt.Errorf("ispconfig: record entry missing name")
+	}
+
+	value, ok := entry["data"].(string)
+	if !ok {
+		return libdns.Record{}, fmt.Errorf("ispconfig: record entry missing data")
+	}
+
+	ttl, err := strconv.ParseInt(entryString(entry, "ttl"), 10, 64)
+	if err != nil {
+		return libdns.Record{}, fmt.Errorf("ispconfig: parsing record ttl: %w", err)
+	}
+
+	priority, _ := strconv.ParseInt(entryString(entry, "aux"), 10, 64)
+
+	var weight int64
+
+	switch strings.ToUpper(rrType) {
+	case "SRV":
+		weight, _ = strconv.ParseInt(entryString(entry, "weight"), 10, 64)
+		port, _ := strconv.ParseInt(entryString(entry, "port"), 10, 64)
+		target := entryString(entry, "target")
+		if target != "" {
+			value = fmt.Sprintf("%d %s", port, target)
+		}
+	case "CAA":
+		flag := entryString(entry, "flag")
+		tag := entryString(entry, "tag")
+		if tag != "" {
+			value = fmt.Sprintf("%s %s \"%s\"", flag, tag, value)
+		}
+	}
+
+	return libdns.Record{
+		ID:       id,
+		Type:     rrType,
+		Name:     name,
+		Value:    value,
+		TTL:      time.Duration(ttl) * time.Second,
+		Priority: uint(priority),
+		Weight:   uint(weight),
+	}, nil
+}
+
+// entryString reads an optional string field off a raw ISPConfig entry,
+// returning "" if it's absent or not a string.
+func entryString(entry map[string]interface{}, key string) string {
+	value, ok := entry[key].(string)
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// splitSRVValue splits the "<port> <target>" representation libdns uses for
+// a SRV record's Value (priority and weight travel separately in Priority
+// and Weight) into its parts.
+func splitSRVValue(value string) (port int, target string) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, value
+	}
+
+	p, _ := strconv.Atoi(fields[0])
+
+	return p, fields[1]
+}
+
+// splitCAAValue splits the standard CAA presentation format
+// (`<flag> <tag> "<value>"`) into its parts.
+func splitCAAValue(value string) (flag int, tag string, data string) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return 0, "", value
+	}
+
+	f, _ := strconv.Atoi(fields[0])
+
+	return f, fields[1], strings.Trim(fields[2], `"`)
+}